@@ -0,0 +1,162 @@
+// Command submit is the thin, synchronous half of the async job API: it
+// records a job for a CSV already uploaded to S3 (via POST /uploads in the
+// root module), enqueues it for cmd/worker to process, and lets callers
+// poll the result. It exists because API Gateway + Lambda's 29s / 15min
+// caps make the root module's synchronous handleRequest unworkable for
+// multi-million row imports.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/google/uuid"
+
+	"github.com/yuktake/go-lambda-csv/internal/idempotency"
+	"github.com/yuktake/go-lambda-csv/internal/jobs"
+)
+
+const idempotencyKeyHeader = "X-Idempotency-Key"
+
+var (
+	jobsTable = os.Getenv("JOBS_TABLE")
+	queueURL  = os.Getenv("JOBS_QUEUE_URL")
+
+	store  *jobs.Store
+	sqsSvc *sqs.SQS
+
+	// idempotencyStore, when configured, lets a caller resubmit the same
+	// X-Idempotency-Key without enqueueing a duplicate job.
+	idempotencyStore *idempotency.Store
+)
+
+func init() {
+	sess := session.Must(session.NewSession())
+	store = jobs.NewStore(dynamodb.New(sess), jobsTable)
+	sqsSvc = sqs.New(sess)
+
+	if table := os.Getenv("IDEMPOTENCY_TABLE"); table != "" {
+		idempotencyStore = idempotency.NewStore(dynamodb.New(sess), table)
+	}
+}
+
+// SubmitRequest is the JSON body for POST /jobs: the bucket/key of a CSV the
+// caller already uploaded via the root module's POST /uploads.
+type SubmitRequest struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+}
+
+// SubmitResponse is returned with 202 Accepted from POST /jobs.
+type SubmitResponse struct {
+	JobID string `json:"jobId"`
+}
+
+func handleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	switch {
+	case request.HTTPMethod == http.MethodPost && request.Path == "/jobs":
+		return handleSubmit(ctx, request)
+	case request.HTTPMethod == http.MethodGet && strings.HasPrefix(request.Path, "/jobs/"):
+		return handleGetJob(ctx, request)
+	}
+	return events.APIGatewayProxyResponse{StatusCode: http.StatusNotFound}, nil
+}
+
+func handleSubmit(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var req SubmitRequest
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Invalid request body"}, nil
+	}
+	if req.Bucket == "" || req.Key == "" {
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "bucket and key are required"}, nil
+	}
+
+	idempotencyKey := request.Headers[idempotencyKeyHeader]
+	if idempotencyKey != "" && idempotencyStore != nil {
+		seen, err := idempotencyStore.CheckAndRecord(ctx, idempotencyKey)
+		if err != nil {
+			log.Printf("Error checking idempotency key: %v", err)
+			return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Error checking idempotency key"}, nil
+		}
+		if seen {
+			return events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: `{"alreadyProcessed":true}`}, nil
+		}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	job := jobs.Job{
+		ID:             uuid.New().String(),
+		Status:         jobs.StatusPending,
+		Bucket:         req.Bucket,
+		Key:            req.Key,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		IdempotencyKey: idempotencyKey,
+	}
+	if err := store.Create(ctx, job); err != nil {
+		log.Printf("Error creating job record: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Error creating job"}, nil
+	}
+
+	msg, err := json.Marshal(job)
+	if err != nil {
+		log.Printf("Error marshalling job message: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Error creating job"}, nil
+	}
+
+	_, err = sqsSvc.SendMessageWithContext(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(queueURL),
+		MessageBody: aws.String(string(msg)),
+	})
+	if err != nil {
+		log.Printf("Error enqueueing job %s: %v", job.ID, err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Error creating job"}, nil
+	}
+
+	respBody, err := json.Marshal(SubmitResponse{JobID: job.ID})
+	if err != nil {
+		log.Printf("Error marshalling submit response: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Error creating job"}, nil
+	}
+
+	return events.APIGatewayProxyResponse{StatusCode: http.StatusAccepted, Body: string(respBody)}, nil
+}
+
+func handleGetJob(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	id := strings.TrimPrefix(request.Path, "/jobs/")
+	if id == "" {
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "job id is required"}, nil
+	}
+
+	job, err := store.Get(ctx, id)
+	if err != nil {
+		log.Printf("Error fetching job %s: %v", id, err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Error fetching job"}, nil
+	}
+	if job == nil {
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusNotFound, Body: "Job not found"}, nil
+	}
+
+	respBody, err := json.Marshal(job)
+	if err != nil {
+		log.Printf("Error marshalling job %s: %v", id, err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Error fetching job"}, nil
+	}
+
+	return events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: string(respBody)}, nil
+}
+
+func main() {
+	lambda.Start(handleRequest)
+}
@@ -0,0 +1,153 @@
+// Command worker is the SQS-triggered half of the async job API: for each
+// queued job from cmd/submit, it streams the referenced CSV out of S3 into
+// DynamoDB via internal/ingest and internal/dynamo, updating the job record
+// in internal/jobs as it goes.
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/yuktake/go-lambda-csv/internal/dynamo"
+	"github.com/yuktake/go-lambda-csv/internal/ingest"
+	"github.com/yuktake/go-lambda-csv/internal/jobs"
+	"github.com/yuktake/go-lambda-csv/internal/schema"
+)
+
+const progressEvery = 1000
+
+var (
+	tableName    = os.Getenv("TABLE_NAME")
+	batchWorkers = envInt("BATCH_WORKERS", 10)
+	csvSchema    *schema.Schema
+
+	// idempotentMode mirrors the root module's IDEMPOTENT toggle: when set,
+	// rows are keyed deterministically and written conditionally instead of
+	// under a random UUID, so retrying a job doesn't duplicate rows.
+	idempotentMode = os.Getenv("IDEMPOTENT") == "true"
+
+	svc   *dynamodb.DynamoDB
+	s3svc *s3.S3
+	store *jobs.Store
+)
+
+func init() {
+	sess := session.Must(session.NewSession())
+	svc = dynamodb.New(sess)
+	s3svc = s3.New(sess)
+	store = jobs.NewStore(dynamodb.New(sess), os.Getenv("JOBS_TABLE"))
+
+	s, err := schema.Load(os.Getenv("SCHEMA_FILE"))
+	if err != nil {
+		log.Printf("Error loading CSV schema, falling back to UUID-only records: %v", err)
+	} else {
+		csvSchema = s
+	}
+}
+
+func envInt(key string, fallback int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+func handleRequest(ctx context.Context, sqsEvent events.SQSEvent) error {
+	for _, record := range sqsEvent.Records {
+		var job jobs.Job
+		if err := json.Unmarshal([]byte(record.Body), &job); err != nil {
+			log.Printf("Error parsing job message: %v", err)
+			continue
+		}
+		processJob(ctx, job)
+	}
+	return nil
+}
+
+func processJob(ctx context.Context, job jobs.Job) {
+	if err := store.SetStatus(ctx, job.ID, jobs.StatusRunning, ""); err != nil {
+		log.Printf("Error marking job %s running: %v", job.ID, err)
+	}
+
+	out, err := s3svc.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(job.Bucket),
+		Key:    aws.String(job.Key),
+	})
+	if err != nil {
+		failJob(ctx, job.ID, err)
+		return
+	}
+	defer out.Body.Close()
+
+	var r io.Reader = out.Body
+	if ingest.IsGzip(job.Key, out.ContentEncoding) {
+		gz, err := gzip.NewReader(out.Body)
+		if err != nil {
+			failJob(ctx, job.ID, err)
+			return
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	onRow := func(rowNum, failed int) {
+		if rowNum%progressEvery == 0 {
+			// failed only counts validation errors caught while reading rows;
+			// write failures (dead letters/conditional-check losses) aren't
+			// known until the batch flushes, so this is a lower bound until
+			// the final store.Complete call below.
+			if err := store.UpdateProgress(ctx, job.ID, rowNum, failed); err != nil {
+				log.Printf("Error updating progress for job %s: %v", job.ID, err)
+			}
+		}
+	}
+
+	var processed, failed int
+	if idempotentMode || job.IdempotencyKey != "" {
+		writer := dynamo.NewIdempotentWriter(svc, tableName, batchWorkers)
+		result, err := ingest.StreamIdempotent(ctx, r, csvSchema, writer, ingest.IdempotentMapRow(csvSchema), 0, onRow)
+		if err != nil {
+			failJob(ctx, job.ID, err)
+			return
+		}
+		processed = result.Inserted + result.Skipped
+		failed = len(result.Errors) + len(result.Failed)
+	} else {
+		batcher := dynamo.NewBatcher(svc, tableName, batchWorkers)
+		result, err := ingest.Stream(ctx, r, csvSchema, batcher, ingest.DefaultMapRow(csvSchema), 0, onRow)
+		if err != nil {
+			failJob(ctx, job.ID, err)
+			return
+		}
+		processed = result.Processed
+		failed = len(result.Errors) + len(result.DeadLetters)
+	}
+
+	if err := store.Complete(ctx, job.ID, processed, failed); err != nil {
+		log.Printf("Error marking job %s complete: %v", job.ID, err)
+	}
+}
+
+func failJob(ctx context.Context, jobID string, err error) {
+	log.Printf("Job %s failed: %v", jobID, err)
+	if serr := store.SetStatus(ctx, jobID, jobs.StatusFailed, err.Error()); serr != nil {
+		log.Printf("Error marking job %s failed: %v", jobID, serr)
+	}
+}
+
+func main() {
+	lambda.Start(handleRequest)
+}
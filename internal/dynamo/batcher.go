@@ -0,0 +1,161 @@
+// Package dynamo groups DynamoDB writes into BatchWriteItem calls and
+// retries throttled or unprocessed items with backoff, instead of issuing
+// one PutItem per row.
+package dynamo
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+const (
+	// maxBatchSize is the BatchWriteItem limit enforced by DynamoDB.
+	maxBatchSize = 25
+	maxRetries   = 5
+	baseBackoff  = 50 * time.Millisecond
+)
+
+// DeadLetter records an item that could not be written after all retries,
+// either because the error was non-retryable or because it was still
+// unprocessed after maxRetries retries.
+type DeadLetter struct {
+	Item   map[string]*dynamodb.AttributeValue `json:"item"`
+	Reason string                              `json:"reason"`
+}
+
+// Batcher groups items sent to Run into BatchWriteItem calls of up to 25,
+// spreading the writes across a bounded pool of workers.
+type Batcher struct {
+	svc     *dynamodb.DynamoDB
+	table   string
+	workers int
+}
+
+// NewBatcher returns a Batcher that writes to table using svc, with workers
+// concurrent BatchWriteItem calls in flight. workers defaults to 10 if <= 0.
+func NewBatcher(svc *dynamodb.DynamoDB, table string, workers int) *Batcher {
+	if workers <= 0 {
+		workers = 10
+	}
+	return &Batcher{svc: svc, table: table, workers: workers}
+}
+
+// Run groups items from in into batches of up to 25 and writes them via
+// BatchWriteItem across b.workers goroutines. It blocks until in is closed
+// and every batch has finished, then returns the items that could not be
+// written — either due to a non-retryable error or because they were still
+// unprocessed after maxRetries retries.
+func (b *Batcher) Run(ctx context.Context, in <-chan map[string]*dynamodb.AttributeValue) []DeadLetter {
+	batches := make(chan []map[string]*dynamodb.AttributeValue)
+	go b.fillBatches(in, batches)
+
+	deadLetters := make(chan DeadLetter)
+	workerDone := make(chan struct{}, b.workers)
+	for i := 0; i < b.workers; i++ {
+		go func() {
+			defer func() { workerDone <- struct{}{} }()
+			for batch := range batches {
+				b.writeBatch(ctx, batch, deadLetters)
+			}
+		}()
+	}
+
+	var report []DeadLetter
+	collectDone := make(chan struct{})
+	go func() {
+		defer close(collectDone)
+		for dl := range deadLetters {
+			report = append(report, dl)
+		}
+	}()
+
+	for i := 0; i < b.workers; i++ {
+		<-workerDone
+	}
+	close(deadLetters)
+	<-collectDone
+
+	return report
+}
+
+func (b *Batcher) fillBatches(in <-chan map[string]*dynamodb.AttributeValue, out chan<- []map[string]*dynamodb.AttributeValue) {
+	defer close(out)
+
+	batch := make([]map[string]*dynamodb.AttributeValue, 0, maxBatchSize)
+	for item := range in {
+		batch = append(batch, item)
+		if len(batch) == maxBatchSize {
+			out <- batch
+			batch = make([]map[string]*dynamodb.AttributeValue, 0, maxBatchSize)
+		}
+	}
+	if len(batch) > 0 {
+		out <- batch
+	}
+}
+
+// writeBatch writes a single batch, retrying with exponential backoff and
+// jitter on throttling and re-queueing UnprocessedItems. Anything still
+// unwritten after maxRetries retries, or that fails with a non-retryable
+// error, is sent to deadLetters.
+func (b *Batcher) writeBatch(ctx context.Context, items []map[string]*dynamodb.AttributeValue, deadLetters chan<- DeadLetter) {
+	reqs := make([]*dynamodb.WriteRequest, len(items))
+	for i, item := range items {
+		reqs[i] = &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: item}}
+	}
+
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		out, err := b.svc.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]*dynamodb.WriteRequest{b.table: reqs},
+		})
+		if err != nil {
+			if isThrottlingError(err) && attempt < maxRetries {
+				sleepBackoff(attempt)
+				continue
+			}
+			sendDeadLetters(deadLetters, reqs, err.Error())
+			return
+		}
+
+		unprocessed := out.UnprocessedItems[b.table]
+		if len(unprocessed) == 0 {
+			return
+		}
+		if attempt == maxRetries {
+			sendDeadLetters(deadLetters, unprocessed, "unprocessed after max retries")
+			return
+		}
+		reqs = unprocessed
+		sleepBackoff(attempt)
+	}
+}
+
+func sendDeadLetters(deadLetters chan<- DeadLetter, reqs []*dynamodb.WriteRequest, reason string) {
+	for _, r := range reqs {
+		deadLetters <- DeadLetter{Item: r.PutRequest.Item, Reason: reason}
+	}
+}
+
+func isThrottlingError(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+	switch aerr.Code() {
+	case dynamodb.ErrCodeProvisionedThroughputExceededException, "ThrottlingException":
+		return true
+	}
+	return false
+}
+
+// sleepBackoff waits an exponentially growing, jittered interval before the
+// next retry attempt (attempt is 0-indexed).
+func sleepBackoff(attempt int) {
+	max := baseBackoff * time.Duration(math.Pow(2, float64(attempt)))
+	time.Sleep(time.Duration(rand.Int63n(int64(max))))
+}
@@ -0,0 +1,66 @@
+package dynamo
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestIsThrottlingError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "provisioned throughput exceeded is throttling",
+			err:  awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "slow down", nil),
+			want: true,
+		},
+		{
+			name: "ThrottlingException is throttling",
+			err:  awserr.New("ThrottlingException", "slow down", nil),
+			want: true,
+		},
+		{
+			name: "conditional check failure is not throttling",
+			err:  awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "already exists", nil),
+			want: false,
+		},
+		{
+			name: "non-awserr error is not throttling",
+			err:  errors.New("boom"),
+			want: false,
+		},
+		{
+			name: "nil error is not throttling",
+			err:  nil,
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isThrottlingError(tt.err); got != tt.want {
+				t.Errorf("isThrottlingError(%v) = %v; want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSleepBackoffBounds(t *testing.T) {
+	for attempt := 0; attempt < 5; attempt++ {
+		want := baseBackoff * (1 << uint(attempt))
+
+		start := time.Now()
+		sleepBackoff(attempt)
+		elapsed := time.Since(start)
+
+		if elapsed > want {
+			t.Errorf("sleepBackoff(%d) took %v; want at most %v", attempt, elapsed, want)
+		}
+	}
+}
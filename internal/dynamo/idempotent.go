@@ -0,0 +1,163 @@
+package dynamo
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Outcome classifies what happened to a single row written by an
+// IdempotentWriter.
+type Outcome string
+
+const (
+	OutcomeInserted         Outcome = "inserted"
+	OutcomeSkippedDuplicate Outcome = "skipped-duplicate"
+	OutcomeFailed           Outcome = "failed"
+)
+
+// RowResult reports the outcome of writing a single item through an
+// IdempotentWriter.
+type RowResult struct {
+	Item    map[string]*dynamodb.AttributeValue `json:"item"`
+	Outcome Outcome                             `json:"outcome"`
+	Reason  string                              `json:"reason,omitempty"`
+}
+
+// IdempotentWriter writes items guarded by
+// ConditionExpression: attribute_not_exists(ID), so retrying the same rows
+// (e.g. after a throttled Lambda invocation) reports them as duplicates
+// instead of overwriting or duplicating them.
+type IdempotentWriter struct {
+	svc     *dynamodb.DynamoDB
+	table   string
+	workers int
+}
+
+// NewIdempotentWriter returns an IdempotentWriter that writes to table using
+// svc, with workers concurrent batches in flight. workers defaults to 10 if
+// <= 0.
+func NewIdempotentWriter(svc *dynamodb.DynamoDB, table string, workers int) *IdempotentWriter {
+	if workers <= 0 {
+		workers = 10
+	}
+	return &IdempotentWriter{svc: svc, table: table, workers: workers}
+}
+
+// Run consumes items from in — each already carrying its deterministic "ID"
+// attribute — and writes them conditionally, reporting every item's
+// outcome. It blocks until in is closed and every batch has finished.
+func (w *IdempotentWriter) Run(ctx context.Context, in <-chan map[string]*dynamodb.AttributeValue) []RowResult {
+	batches := make(chan []map[string]*dynamodb.AttributeValue)
+	go w.fillBatches(in, batches)
+
+	results := make(chan RowResult)
+	workerDone := make(chan struct{}, w.workers)
+	for i := 0; i < w.workers; i++ {
+		go func() {
+			defer func() { workerDone <- struct{}{} }()
+			for batch := range batches {
+				w.writeBatch(ctx, batch, results)
+			}
+		}()
+	}
+
+	var report []RowResult
+	collectDone := make(chan struct{})
+	go func() {
+		defer close(collectDone)
+		for r := range results {
+			report = append(report, r)
+		}
+	}()
+
+	for i := 0; i < w.workers; i++ {
+		<-workerDone
+	}
+	close(results)
+	<-collectDone
+
+	return report
+}
+
+func (w *IdempotentWriter) fillBatches(in <-chan map[string]*dynamodb.AttributeValue, out chan<- []map[string]*dynamodb.AttributeValue) {
+	defer close(out)
+
+	batch := make([]map[string]*dynamodb.AttributeValue, 0, maxBatchSize)
+	for item := range in {
+		batch = append(batch, item)
+		if len(batch) == maxBatchSize {
+			out <- batch
+			batch = make([]map[string]*dynamodb.AttributeValue, 0, maxBatchSize)
+		}
+	}
+	if len(batch) > 0 {
+		out <- batch
+	}
+}
+
+// writeBatch tries to write the whole batch in one TransactWriteItems call,
+// retrying with backoff on throttling. If the transaction is cancelled for
+// any other reason — most likely because one or more rows already exist —
+// it falls back to a conditional PutItem per row, so each row's outcome can
+// be reported individually.
+func (w *IdempotentWriter) writeBatch(ctx context.Context, items []map[string]*dynamodb.AttributeValue, results chan<- RowResult) {
+	transactItems := make([]*dynamodb.TransactWriteItem, len(items))
+	for i, item := range items {
+		transactItems[i] = &dynamodb.TransactWriteItem{
+			Put: &dynamodb.Put{
+				TableName:           aws.String(w.table),
+				Item:                item,
+				ConditionExpression: aws.String("attribute_not_exists(ID)"),
+			},
+		}
+	}
+
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		_, err := w.svc.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: transactItems})
+		if err == nil {
+			for _, item := range items {
+				results <- RowResult{Item: item, Outcome: OutcomeInserted}
+			}
+			return
+		}
+		if isThrottlingError(err) && attempt < maxRetries {
+			sleepBackoff(attempt)
+			continue
+		}
+		break
+	}
+
+	for _, item := range items {
+		w.writeOne(ctx, item, results)
+	}
+}
+
+func (w *IdempotentWriter) writeOne(ctx context.Context, item map[string]*dynamodb.AttributeValue, results chan<- RowResult) {
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		_, err := w.svc.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+			TableName:           aws.String(w.table),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(ID)"),
+		})
+		if err == nil {
+			results <- RowResult{Item: item, Outcome: OutcomeInserted}
+			return
+		}
+
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			results <- RowResult{Item: item, Outcome: OutcomeSkippedDuplicate}
+			return
+		}
+
+		if isThrottlingError(err) && attempt < maxRetries {
+			sleepBackoff(attempt)
+			continue
+		}
+
+		results <- RowResult{Item: item, Outcome: OutcomeFailed, Reason: err.Error()}
+		return
+	}
+}
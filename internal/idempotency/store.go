@@ -0,0 +1,48 @@
+// Package idempotency tracks X-Idempotency-Key values that have already
+// been processed, so a caller retrying the same file submission can
+// short-circuit instead of re-ingesting it.
+package idempotency
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Store records seen idempotency keys in a DynamoDB table keyed on Key.
+type Store struct {
+	svc   *dynamodb.DynamoDB
+	table string
+}
+
+// NewStore returns a Store backed by table.
+func NewStore(svc *dynamodb.DynamoDB, table string) *Store {
+	return &Store{svc: svc, table: table}
+}
+
+// CheckAndRecord atomically records key as processed and reports whether it
+// had already been recorded. Using a single conditional write, rather than
+// a Seen check followed by a separate Record, avoids the race where two
+// concurrent requests for the same key both observe "not yet seen" and both
+// proceed to process it.
+func (s *Store) CheckAndRecord(ctx context.Context, key string) (alreadySeen bool, err error) {
+	_, err = s.svc.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item: map[string]*dynamodb.AttributeValue{
+			"Key": {S: aws.String(key)},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(#k)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#k": aws.String("Key"),
+		},
+	})
+	if err == nil {
+		return false, nil
+	}
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return true, nil
+	}
+	return false, err
+}
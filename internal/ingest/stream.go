@@ -0,0 +1,249 @@
+// Package ingest streams CSV data into DynamoDB through a schema and a
+// batched writer, shared by the inline-body, S3 streaming (POST /ingest),
+// and async worker ingestion paths.
+package ingest
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/google/uuid"
+
+	"github.com/yuktake/go-lambda-csv/internal/dynamo"
+	"github.com/yuktake/go-lambda-csv/internal/schema"
+)
+
+// MapRowFunc converts a single CSV row into a DynamoDB item, or a
+// validation error explaining why it couldn't be.
+type MapRowFunc func(rowNum int, row, header []string) (map[string]*dynamodb.AttributeValue, *schema.ValidationError)
+
+// DefaultMapRow returns a MapRowFunc that maps each row through sch, or —
+// if sch is nil — generates a bare UUID record, matching the module's
+// original per-row behavior.
+func DefaultMapRow(sch *schema.Schema) MapRowFunc {
+	return func(rowNum int, row, header []string) (map[string]*dynamodb.AttributeValue, *schema.ValidationError) {
+		if sch == nil {
+			av, err := dynamodbattribute.MarshalMap(struct{ ID string }{ID: uuid.New().String()})
+			if err != nil {
+				return nil, &schema.ValidationError{Row: rowNum, Reason: err.Error()}
+			}
+			return av, nil
+		}
+		return sch.MapRow(rowNum, row, header)
+	}
+}
+
+// IdempotentMapRow returns a MapRowFunc like DefaultMapRow, except the "ID"
+// attribute is overwritten with a deterministic key derived from the row
+// (sch.DeriveKey) instead of a random UUID, so re-processing the same CSV
+// — e.g. after a throttled retry — doesn't duplicate rows when written
+// through an *dynamo.IdempotentWriter.
+func IdempotentMapRow(sch *schema.Schema) MapRowFunc {
+	return func(rowNum int, row, header []string) (map[string]*dynamodb.AttributeValue, *schema.ValidationError) {
+		item := map[string]*dynamodb.AttributeValue{}
+		if sch != nil {
+			mapped, verr := sch.MapRow(rowNum, row, header)
+			if verr != nil {
+				return nil, verr
+			}
+			item = mapped
+		}
+		item["ID"] = &dynamodb.AttributeValue{S: aws.String(sch.DeriveKey(row, header))}
+		return item, nil
+	}
+}
+
+// Result summarizes one streamed CSV: how many rows were written, and which
+// ones could not be.
+type Result struct {
+	Processed   int
+	Errors      []schema.ValidationError
+	DeadLetters []dynamo.DeadLetter
+	// NextOffset is the last row number read before Stream stopped, whether
+	// it stopped because the file was exhausted or because ctx was
+	// cancelled (e.g. the Lambda invocation is about to time out). Callers
+	// that support resuming (streaming.go's handleStreamRequest) should
+	// surface it to the caller instead of leaving resumption to a
+	// CloudWatch log line.
+	NextOffset int
+}
+
+// Stream reads r as CSV and writes each row through batcher, using mapRow to
+// convert rows into DynamoDB items. If sch is configured for header-row
+// mode, the first line is parsed as the header and passed to mapRow for
+// every subsequent row. offset skips that many already
+// processed data rows, so a timed-out invocation can resume. onRow, if
+// non-nil, is called after every row is read (including skipped or invalid
+// ones) with the 1-indexed row number and the number of validation errors
+// seen so far, for progress checkpoints. That failed count doesn't include
+// write failures (dead letters/conditional-check losses), which aren't
+// known until the batch finishes; callers needing those should treat it as
+// a lower bound until the final Result. Stream also stops early and
+// returns ctx.Err() if ctx is cancelled mid-file.
+func Stream(ctx context.Context, r io.Reader, sch *schema.Schema, batcher *dynamo.Batcher, mapRow MapRowFunc, offset int, onRow func(rowNum, failed int)) (Result, error) {
+	csvReader := csv.NewReader(r)
+	csvReader.LazyQuotes = true
+
+	header, err := readHeader(csvReader, sch)
+	if err != nil {
+		return Result{}, err
+	}
+
+	items, reportCh, readErrCh := produceItems(ctx, csvReader, header, mapRow, offset, onRow)
+	deadLetters := batcher.Run(ctx, items)
+	pr := <-reportCh
+	err = <-readErrCh
+
+	return Result{
+		Processed:   pr.sent - len(deadLetters),
+		Errors:      pr.errs,
+		DeadLetters: deadLetters,
+		NextOffset:  pr.lastRow,
+	}, err
+}
+
+// IdempotentResult summarizes one CSV streamed through an
+// *dynamo.IdempotentWriter: how many rows were newly inserted, how many
+// were already present (skipped as duplicates), and which ones failed
+// outright.
+type IdempotentResult struct {
+	Inserted int
+	Skipped  int
+	Errors   []schema.ValidationError
+	Failed   []dynamo.RowResult
+	// NextOffset is the last row number read before StreamIdempotent
+	// stopped; see Result.NextOffset.
+	NextOffset int
+}
+
+// StreamIdempotent is like Stream, but writes through an
+// *dynamo.IdempotentWriter so retrying the same CSV reports already-written
+// rows as skipped duplicates instead of overwriting or duplicating them.
+func StreamIdempotent(ctx context.Context, r io.Reader, sch *schema.Schema, writer *dynamo.IdempotentWriter, mapRow MapRowFunc, offset int, onRow func(rowNum, failed int)) (IdempotentResult, error) {
+	csvReader := csv.NewReader(r)
+	csvReader.LazyQuotes = true
+
+	header, err := readHeader(csvReader, sch)
+	if err != nil {
+		return IdempotentResult{}, err
+	}
+
+	items, reportCh, readErrCh := produceItems(ctx, csvReader, header, mapRow, offset, onRow)
+	results := writer.Run(ctx, items)
+	pr := <-reportCh
+	err = <-readErrCh
+
+	result := IdempotentResult{Errors: pr.errs, NextOffset: pr.lastRow}
+	for _, r := range results {
+		switch r.Outcome {
+		case dynamo.OutcomeInserted:
+			result.Inserted++
+		case dynamo.OutcomeSkippedDuplicate:
+			result.Skipped++
+		default:
+			result.Failed = append(result.Failed, r)
+		}
+	}
+
+	return result, err
+}
+
+// readHeader parses the first CSV line as a header when sch is configured
+// for header-row mode. It does so on resumed invocations too: each one
+// reads the object from its start, so the header is always the first line
+// and offset counts data rows only.
+func readHeader(csvReader *csv.Reader, sch *schema.Schema) ([]string, error) {
+	if sch == nil || !sch.HeaderRow {
+		return nil, nil
+	}
+	return csvReader.Read()
+}
+
+// produceReport carries the bookkeeping produceItems accumulates while
+// reading rows, delivered once csvReader is exhausted.
+type produceReport struct {
+	errs    []schema.ValidationError
+	sent    int
+	lastRow int
+}
+
+// produceItems reads rows from csvReader, mapping each into a DynamoDB item
+// with mapRow and sending it on the returned channel. It's shared by Stream
+// and StreamIdempotent, which differ only in how items are written. It
+// stops early, with readErr receiving ctx.Err(), if ctx is cancelled before
+// the file is exhausted — e.g. the caller's Lambda invocation is about to
+// time out — so callers can report the last row reached instead of letting
+// the invocation be killed mid-file with no trace but a log line.
+func produceItems(ctx context.Context, csvReader *csv.Reader, header []string, mapRow MapRowFunc, offset int, onRow func(rowNum, failed int)) (<-chan map[string]*dynamodb.AttributeValue, <-chan produceReport, <-chan error) {
+	items := make(chan map[string]*dynamodb.AttributeValue)
+	reportCh := make(chan produceReport, 1)
+	readErr := make(chan error, 1)
+
+	go func() {
+		defer close(items)
+		var pr produceReport
+		rowNum := 0
+		for {
+			select {
+			case <-ctx.Done():
+				pr.lastRow = rowNum
+				readErr <- ctx.Err()
+				reportCh <- pr
+				return
+			default:
+			}
+
+			row, err := csvReader.Read()
+			if err == io.EOF {
+				pr.lastRow = rowNum
+				readErr <- nil
+				reportCh <- pr
+				return
+			}
+			if err != nil {
+				pr.lastRow = rowNum
+				readErr <- err
+				reportCh <- pr
+				return
+			}
+
+			rowNum++
+			if rowNum <= offset {
+				if onRow != nil {
+					onRow(rowNum, len(pr.errs))
+				}
+				continue // already processed before a prior timeout
+			}
+
+			av, verr := mapRow(rowNum, row, header)
+			if verr != nil {
+				pr.errs = append(pr.errs, *verr)
+				if onRow != nil {
+					onRow(rowNum, len(pr.errs))
+				}
+				continue
+			}
+			items <- av
+			pr.sent++
+			if onRow != nil {
+				onRow(rowNum, len(pr.errs))
+			}
+		}
+	}()
+
+	return items, reportCh, readErr
+}
+
+// IsGzip reports whether an S3 object is gzip-compressed, based on its key
+// suffix or Content-Encoding metadata.
+func IsGzip(key string, contentEncoding *string) bool {
+	if strings.HasSuffix(key, ".csv.gz") || strings.HasSuffix(key, ".gz") {
+		return true
+	}
+	return contentEncoding != nil && *contentEncoding == "gzip"
+}
@@ -0,0 +1,148 @@
+// Package jobs tracks the progress of asynchronous CSV imports in a
+// DynamoDB table, for the submit/worker/poll API in cmd/submit and
+// cmd/worker.
+package jobs
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// Status is the lifecycle state of a Job.
+type Status string
+
+const (
+	StatusPending   Status = "PENDING"
+	StatusRunning   Status = "RUNNING"
+	StatusCompleted Status = "COMPLETED"
+	StatusFailed    Status = "FAILED"
+)
+
+// Job tracks one asynchronous CSV import: the S3 object it reads from, its
+// current status, and row counts once processing starts.
+type Job struct {
+	ID        string `json:"id" dynamodbav:"ID"`
+	Status    Status `json:"status" dynamodbav:"Status"`
+	Bucket    string `json:"bucket" dynamodbav:"Bucket"`
+	Key       string `json:"key" dynamodbav:"Key"`
+	Processed int    `json:"processed" dynamodbav:"Processed"`
+	Failed    int    `json:"failed" dynamodbav:"Failed"`
+	Error     string `json:"error,omitempty" dynamodbav:"Error,omitempty"`
+	CreatedAt string `json:"createdAt" dynamodbav:"CreatedAt"`
+	UpdatedAt string `json:"updatedAt" dynamodbav:"UpdatedAt"`
+	// IdempotencyKey, if set, is the caller-supplied X-Idempotency-Key this
+	// job was submitted with. cmd/worker writes the rows of a job that has
+	// one idempotently (deterministic keys, conditional writes), even when
+	// IDEMPOTENT is not set.
+	IdempotencyKey string `json:"idempotencyKey,omitempty" dynamodbav:"IdempotencyKey,omitempty"`
+}
+
+// Store persists Job records to a DynamoDB table keyed on ID.
+type Store struct {
+	svc   *dynamodb.DynamoDB
+	table string
+}
+
+// NewStore returns a Store backed by table.
+func NewStore(svc *dynamodb.DynamoDB, table string) *Store {
+	return &Store{svc: svc, table: table}
+}
+
+// Create writes a new job record.
+func (s *Store) Create(ctx context.Context, job Job) error {
+	av, err := dynamodbattribute.MarshalMap(job)
+	if err != nil {
+		return err
+	}
+	_, err = s.svc.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item:      av,
+	})
+	return err
+}
+
+// Get fetches a job by ID. It returns a nil Job and nil error if no job
+// with that ID exists.
+func (s *Store) Get(ctx context.Context, id string) (*Job, error) {
+	out, err := s.svc.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"ID": {S: aws.String(id)},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.Item == nil {
+		return nil, nil
+	}
+
+	var job Job
+	if err := dynamodbattribute.UnmarshalMap(out.Item, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// UpdateProgress records how many rows have been processed/failed so far,
+// without changing the job's status.
+func (s *Store) UpdateProgress(ctx context.Context, id string, processed, failed int) error {
+	return s.update(ctx, id, "SET Processed = :p, Failed = :f, UpdatedAt = :u", map[string]*dynamodb.AttributeValue{
+		":p": {N: aws.String(strconv.Itoa(processed))},
+		":f": {N: aws.String(strconv.Itoa(failed))},
+		":u": {S: aws.String(now())},
+	})
+}
+
+// SetStatus transitions a job to status, optionally recording an error
+// message (for StatusFailed).
+func (s *Store) SetStatus(ctx context.Context, id string, status Status, errMsg string) error {
+	return s.update(ctx, id, "SET #s = :s, #e = :e, UpdatedAt = :u", map[string]*dynamodb.AttributeValue{
+		":s": {S: aws.String(string(status))},
+		":e": {S: aws.String(errMsg)},
+		":u": {S: aws.String(now())},
+	}, withNames(map[string]*string{"#s": aws.String("Status"), "#e": aws.String("Error")}))
+}
+
+// Complete marks a job COMPLETED with its final row counts.
+func (s *Store) Complete(ctx context.Context, id string, processed, failed int) error {
+	return s.update(ctx, id, "SET #s = :s, Processed = :p, Failed = :f, UpdatedAt = :u", map[string]*dynamodb.AttributeValue{
+		":s": {S: aws.String(string(StatusCompleted))},
+		":p": {N: aws.String(strconv.Itoa(processed))},
+		":f": {N: aws.String(strconv.Itoa(failed))},
+		":u": {S: aws.String(now())},
+	}, withNames(map[string]*string{"#s": aws.String("Status")}))
+}
+
+type updateOpt func(*dynamodb.UpdateItemInput)
+
+func withNames(names map[string]*string) updateOpt {
+	return func(in *dynamodb.UpdateItemInput) {
+		in.ExpressionAttributeNames = names
+	}
+}
+
+func (s *Store) update(ctx context.Context, id, expr string, values map[string]*dynamodb.AttributeValue, opts ...updateOpt) error {
+	in := &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"ID": {S: aws.String(id)},
+		},
+		UpdateExpression:          aws.String(expr),
+		ExpressionAttributeValues: values,
+	}
+	for _, opt := range opts {
+		opt(in)
+	}
+	_, err := s.svc.UpdateItemWithContext(ctx, in)
+	return err
+}
+
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
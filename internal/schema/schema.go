@@ -0,0 +1,204 @@
+// Package schema maps CSV rows into DynamoDB items according to a
+// configured set of typed, validated columns, instead of discarding every
+// column but a generated ID.
+package schema
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// ColumnType is a DynamoDB scalar attribute type a CSV column can map to.
+type ColumnType string
+
+const (
+	TypeString ColumnType = "S"
+	TypeNumber ColumnType = "N"
+	TypeBinary ColumnType = "B"
+	TypeBool   ColumnType = "BOOL"
+)
+
+// Column describes one CSV column: its DynamoDB attribute name and type,
+// whether it must be present, and optional validators.
+type Column struct {
+	Name     string     `json:"name"`
+	Type     ColumnType `json:"type"`
+	Required bool       `json:"required"`
+	// Pattern, if set, is a regexp the raw string value must match.
+	Pattern string `json:"pattern,omitempty"`
+	// Min and Max, if set, bound a Number column's value (inclusive).
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+}
+
+// Schema describes the columns of an incoming CSV and how rows are mapped
+// into DynamoDB items.
+type Schema struct {
+	Columns []Column `json:"columns"`
+	// HeaderRow indicates the first line of the CSV names its columns; when
+	// false, Columns must appear in the same order as the CSV itself.
+	HeaderRow bool `json:"headerRow"`
+	// IdempotencyKeyColumn, if set, names the column whose value is used as
+	// the deterministic primary key in idempotent ingestion mode. If unset,
+	// the key is a SHA-256 hash of the whole row instead.
+	IdempotencyKeyColumn string `json:"idempotencyKeyColumn,omitempty"`
+}
+
+// Load reads a Schema as JSON from the CSV_SCHEMA env var if it's set, or
+// from the file at path otherwise. A nil Schema and nil error means no
+// schema is configured and callers should fall back to legacy behavior.
+func Load(path string) (*Schema, error) {
+	if raw := os.Getenv("CSV_SCHEMA"); raw != "" {
+		var s Schema
+		if err := json.Unmarshal([]byte(raw), &s); err != nil {
+			return nil, fmt.Errorf("parsing CSV_SCHEMA: %w", err)
+		}
+		return &s, nil
+	}
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading schema file %s: %w", path, err)
+	}
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parsing schema file %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// ValidationError reports why a single row/column could not be mapped.
+type ValidationError struct {
+	Row    int    `json:"row"`
+	Column string `json:"column"`
+	Reason string `json:"reason"`
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("row %d, column %q: %s", e.Row, e.Column, e.Reason)
+}
+
+// MapRow validates and converts a single CSV row into a DynamoDB item.
+// rowNum is used only for error reporting. header is the parsed header line
+// in HeaderRow mode, and is ignored in positional mode.
+func (s *Schema) MapRow(rowNum int, row, header []string) (map[string]*dynamodb.AttributeValue, *ValidationError) {
+	item := make(map[string]*dynamodb.AttributeValue, len(s.Columns))
+
+	for i, col := range s.Columns {
+		idx := i
+		if s.HeaderRow {
+			idx = indexOf(header, col.Name)
+		}
+		if idx < 0 || idx >= len(row) {
+			if col.Required {
+				return nil, &ValidationError{Row: rowNum, Column: col.Name, Reason: "missing value"}
+			}
+			continue
+		}
+
+		value := row[idx]
+		if value == "" {
+			if col.Required {
+				return nil, &ValidationError{Row: rowNum, Column: col.Name, Reason: "required value is empty"}
+			}
+			continue
+		}
+
+		av, err := col.toAttributeValue(value)
+		if err != nil {
+			return nil, &ValidationError{Row: rowNum, Column: col.Name, Reason: err.Error()}
+		}
+		item[col.Name] = av
+	}
+
+	return item, nil
+}
+
+// DeriveKey returns the deterministic primary key for row, for idempotent
+// ingestion mode: the value of s.IdempotencyKeyColumn if one is configured,
+// or a SHA-256 hash of the row otherwise. s may be nil, in which case the
+// row is always hashed. header is only consulted in HeaderRow mode.
+func (s *Schema) DeriveKey(row, header []string) string {
+	if s != nil && s.IdempotencyKeyColumn != "" {
+		idx := indexOf(header, s.IdempotencyKeyColumn)
+		if s.HeaderRow && idx >= 0 && idx < len(row) {
+			return row[idx]
+		}
+		if !s.HeaderRow {
+			for i, col := range s.Columns {
+				if col.Name == s.IdempotencyKeyColumn && i < len(row) {
+					return row[i]
+				}
+			}
+		}
+	}
+	return HashRow(row)
+}
+
+// HashRow returns a SHA-256 hash of row's normalized values, hex-encoded.
+func HashRow(row []string) string {
+	parts := make([]string, len(row))
+	for i, v := range row {
+		parts[i] = strings.TrimSpace(v)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x1f")))
+	return hex.EncodeToString(sum[:])
+}
+
+func indexOf(header []string, name string) int {
+	for i, h := range header {
+		if h == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c Column) toAttributeValue(value string) (*dynamodb.AttributeValue, error) {
+	if c.Pattern != "" {
+		matched, err := regexp.MatchString(c.Pattern, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", c.Pattern, err)
+		}
+		if !matched {
+			return nil, fmt.Errorf("value %q does not match pattern %q", value, c.Pattern)
+		}
+	}
+
+	switch c.Type {
+	case TypeNumber:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("value %q is not a number", value)
+		}
+		if c.Min != nil && n < *c.Min {
+			return nil, fmt.Errorf("value %v is below minimum %v", n, *c.Min)
+		}
+		if c.Max != nil && n > *c.Max {
+			return nil, fmt.Errorf("value %v is above maximum %v", n, *c.Max)
+		}
+		return &dynamodb.AttributeValue{N: aws.String(value)}, nil
+	case TypeBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("value %q is not a bool", value)
+		}
+		return &dynamodb.AttributeValue{BOOL: aws.Bool(b)}, nil
+	case TypeBinary:
+		return &dynamodb.AttributeValue{B: []byte(value)}, nil
+	default:
+		return &dynamodb.AttributeValue{S: aws.String(value)}, nil
+	}
+}
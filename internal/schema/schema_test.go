@@ -0,0 +1,216 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestSchemaMapRow(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  Schema
+		header  []string
+		row     []string
+		want    map[string]*dynamodb.AttributeValue
+		wantErr string // Column of the expected *ValidationError, "" if none expected
+	}{
+		{
+			name: "positional mode maps columns by index",
+			schema: Schema{
+				Columns: []Column{
+					{Name: "id", Type: TypeString, Required: true},
+					{Name: "age", Type: TypeNumber},
+				},
+			},
+			row: []string{"abc", "42"},
+			want: map[string]*dynamodb.AttributeValue{
+				"id":  {S: aws.String("abc")},
+				"age": {N: aws.String("42")},
+			},
+		},
+		{
+			name: "header row mode maps columns by name regardless of CSV order",
+			schema: Schema{
+				HeaderRow: true,
+				Columns: []Column{
+					{Name: "id", Type: TypeString, Required: true},
+					{Name: "age", Type: TypeNumber},
+				},
+			},
+			header: []string{"age", "id"},
+			row:    []string{"42", "abc"},
+			want: map[string]*dynamodb.AttributeValue{
+				"id":  {S: aws.String("abc")},
+				"age": {N: aws.String("42")},
+			},
+		},
+		{
+			name: "missing required column fails",
+			schema: Schema{
+				Columns: []Column{
+					{Name: "id", Type: TypeString, Required: true},
+				},
+			},
+			row:     []string{},
+			wantErr: "id",
+		},
+		{
+			name: "empty value for a required column fails",
+			schema: Schema{
+				Columns: []Column{
+					{Name: "id", Type: TypeString, Required: true},
+				},
+			},
+			row:     []string{""},
+			wantErr: "id",
+		},
+		{
+			name: "missing optional column is skipped",
+			schema: Schema{
+				Columns: []Column{
+					{Name: "nickname", Type: TypeString},
+				},
+			},
+			row:  []string{},
+			want: map[string]*dynamodb.AttributeValue{},
+		},
+		{
+			name: "non-numeric value for a number column fails",
+			schema: Schema{
+				Columns: []Column{
+					{Name: "age", Type: TypeNumber},
+				},
+			},
+			row:     []string{"not-a-number"},
+			wantErr: "age",
+		},
+		{
+			name: "number below minimum fails",
+			schema: Schema{
+				Columns: []Column{
+					{Name: "age", Type: TypeNumber, Min: aws.Float64(0)},
+				},
+			},
+			row:     []string{"-1"},
+			wantErr: "age",
+		},
+		{
+			name: "number above maximum fails",
+			schema: Schema{
+				Columns: []Column{
+					{Name: "age", Type: TypeNumber, Max: aws.Float64(100)},
+				},
+			},
+			row:     []string{"101"},
+			wantErr: "age",
+		},
+		{
+			name: "value not matching pattern fails",
+			schema: Schema{
+				Columns: []Column{
+					{Name: "zip", Type: TypeString, Pattern: `^\d{5}$`},
+				},
+			},
+			row:     []string{"abc"},
+			wantErr: "zip",
+		},
+		{
+			name: "bool column parses true",
+			schema: Schema{
+				Columns: []Column{
+					{Name: "active", Type: TypeBool},
+				},
+			},
+			row: []string{"true"},
+			want: map[string]*dynamodb.AttributeValue{
+				"active": {BOOL: aws.Bool(true)},
+			},
+		},
+		{
+			name: "binary column stores raw bytes",
+			schema: Schema{
+				Columns: []Column{
+					{Name: "blob", Type: TypeBinary},
+				},
+			},
+			row: []string{"hello"},
+			want: map[string]*dynamodb.AttributeValue{
+				"blob": {B: []byte("hello")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item, verr := tt.schema.MapRow(1, tt.row, tt.header)
+
+			if tt.wantErr != "" {
+				if verr == nil {
+					t.Fatalf("MapRow() = %v, nil error; want error for column %q", item, tt.wantErr)
+				}
+				if verr.Column != tt.wantErr {
+					t.Fatalf("MapRow() error column = %q; want %q", verr.Column, tt.wantErr)
+				}
+				return
+			}
+
+			if verr != nil {
+				t.Fatalf("MapRow() unexpected error: %v", verr)
+			}
+			if len(item) != len(tt.want) {
+				t.Fatalf("MapRow() = %v; want %v", item, tt.want)
+			}
+			for k, wantAV := range tt.want {
+				gotAV, ok := item[k]
+				if !ok {
+					t.Fatalf("MapRow() missing attribute %q; want %v", k, wantAV)
+				}
+				if gotAV.String() != wantAV.String() {
+					t.Fatalf("MapRow() attribute %q = %v; want %v", k, gotAV, wantAV)
+				}
+			}
+		})
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	row := []string{"abc", "42"}
+
+	t.Run("nil schema hashes the row", func(t *testing.T) {
+		var s *Schema
+		if got, want := s.DeriveKey(row, nil), HashRow(row); got != want {
+			t.Fatalf("DeriveKey() = %q; want %q", got, want)
+		}
+	})
+
+	t.Run("no IdempotencyKeyColumn hashes the row", func(t *testing.T) {
+		s := &Schema{Columns: []Column{{Name: "id"}, {Name: "age"}}}
+		if got, want := s.DeriveKey(row, nil), HashRow(row); got != want {
+			t.Fatalf("DeriveKey() = %q; want %q", got, want)
+		}
+	})
+
+	t.Run("positional mode uses the configured column's value", func(t *testing.T) {
+		s := &Schema{
+			Columns:              []Column{{Name: "id"}, {Name: "age"}},
+			IdempotencyKeyColumn: "id",
+		}
+		if got, want := s.DeriveKey(row, nil), "abc"; got != want {
+			t.Fatalf("DeriveKey() = %q; want %q", got, want)
+		}
+	})
+
+	t.Run("header row mode uses the configured column's value", func(t *testing.T) {
+		s := &Schema{
+			HeaderRow:            true,
+			Columns:              []Column{{Name: "id"}, {Name: "age"}},
+			IdempotencyKeyColumn: "age",
+		}
+		header := []string{"id", "age"}
+		if got, want := s.DeriveKey(row, header), "42"; got != want {
+			t.Fatalf("DeriveKey() = %q; want %q", got, want)
+		}
+	})
+}
@@ -2,32 +2,58 @@ package main
 
 import (
 	"context"
-	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"mime/multipart"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
-	"sync"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
-	"github.com/google/uuid"
+
+	"github.com/yuktake/go-lambda-csv/internal/dynamo"
+	"github.com/yuktake/go-lambda-csv/internal/idempotency"
+	"github.com/yuktake/go-lambda-csv/internal/ingest"
+	"github.com/yuktake/go-lambda-csv/internal/schema"
 )
 
 var (
-	tableName = os.Getenv("TABLE_NAME")
-	svc       *dynamodb.DynamoDB
+	tableName    = os.Getenv("TABLE_NAME")
+	batchWorkers = envInt("BATCH_WORKERS", 10)
+	svc          *dynamodb.DynamoDB
+	csvSchema    *schema.Schema
+
+	// idempotentMode, when enabled, derives each row's key deterministically
+	// and writes it conditionally instead of assigning a random UUID, so
+	// retrying the same file doesn't duplicate rows.
+	idempotentMode = os.Getenv("IDEMPOTENT") == "true"
+	// idempotencyStore, when configured, lets a caller short-circuit
+	// resubmitting an entire file via the X-Idempotency-Key header.
+	idempotencyStore *idempotency.Store
 )
 
-type Record struct {
-	ID string
+// IngestReport is the response body for a completed ingestion request. In
+// the default mode, Processed/Errors/DeadLetters are populated; in
+// idempotent mode (IDEMPOTENT=true), Inserted/Skipped/Failed are used
+// instead so callers can tell newly written rows from ones already present
+// from an earlier attempt.
+type IngestReport struct {
+	Processed   int                      `json:"processed,omitempty"`
+	Inserted    int                      `json:"inserted,omitempty"`
+	Skipped     int                      `json:"skipped,omitempty"`
+	Errors      []schema.ValidationError `json:"errors,omitempty"`
+	DeadLetters []dynamo.DeadLetter      `json:"deadLetters,omitempty"`
+	Failed      []dynamo.RowResult       `json:"failed,omitempty"`
+	// NextOffset is the last row reached before ingestion stopped. It's only
+	// meaningful when err is non-nil (e.g. the invocation is about to time
+	// out): callers should pass it back as StreamRequest.Offset to resume.
+	NextOffset int `json:"nextOffset,omitempty"`
 }
 
 // mainパッケージに書くとmain関数より先に実行されます。
@@ -35,42 +61,76 @@ type Record struct {
 func init() {
 	sess := session.Must(session.NewSession())
 	svc = dynamodb.New(sess)
-}
-
-func processRecord(record []string, sem chan struct{}, wg *sync.WaitGroup) {
-	// wg.Done()を呼び出すことで、goroutineの数をカウントダウンします
-	defer wg.Done()
-	// deferを使って、goroutineの処理が終了したらセマフォをリリースする
-	defer func() { <-sem }() // セマフォからリリース
 
-	// ここで各レコードの処理を行います
-	log.Printf("Processing record: %v", record)
+	s, err := schema.Load(os.Getenv("SCHEMA_FILE"))
+	if err != nil {
+		log.Printf("Error loading CSV schema, falling back to UUID-only records: %v", err)
+	} else {
+		csvSchema = s
+	}
 
-	id := uuid.New().String()
-	item := Record{
-		ID: id,
+	if table := os.Getenv("IDEMPOTENCY_TABLE"); table != "" {
+		idempotencyStore = idempotency.NewStore(dynamodb.New(sess), table)
 	}
-	av, err := dynamodbattribute.MarshalMap(item)
+}
+
+func envInt(key string, fallback int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
 	if err != nil {
-		fmt.Println("Got error marshalling new item:")
-		fmt.Println(err.Error())
-		os.Exit(1)
+		return fallback
 	}
+	return v
+}
 
-	// Put item into DynamoDB
-	input := &dynamodb.PutItemInput{
-		TableName: aws.String(tableName),
-		Item:      av,
+// idempotencyKeyHeader is the optional request header a caller can send to
+// make resubmitting the same file a no-op.
+const idempotencyKeyHeader = "X-Idempotency-Key"
+
+// checkIdempotencyKey records request's X-Idempotency-Key header, if any,
+// against idempotencyStore, and reports whether it was already recorded by
+// an earlier request. The check-and-record is atomic, so two concurrent
+// requests for the same key can't both observe "not yet seen" and both
+// proceed to process the file.
+func checkIdempotencyKey(ctx context.Context, request events.APIGatewayProxyRequest) (seen bool, err error) {
+	key := request.Headers[idempotencyKeyHeader]
+	if key == "" || idempotencyStore == nil {
+		return false, nil
 	}
+	return idempotencyStore.CheckAndRecord(ctx, key)
+}
 
-	_, err = svc.PutItem(input)
-	if err != nil {
-		log.Printf("Error putting item in DynamoDB: %v", err)
-		return // エラーが発生した場合は処理を中断
+// ingestCSV streams r into DynamoDB, using idempotent writes when
+// idempotentMode is enabled. offset and onRow let callers resume a
+// partially processed file and checkpoint progress, respectively.
+func ingestCSV(ctx context.Context, r io.Reader, offset int, onRow func(rowNum, failed int)) (IngestReport, error) {
+	if idempotentMode {
+		writer := dynamo.NewIdempotentWriter(svc, tableName, batchWorkers)
+		result, err := ingest.StreamIdempotent(ctx, r, csvSchema, writer, ingest.IdempotentMapRow(csvSchema), offset, onRow)
+		report := IngestReport{Inserted: result.Inserted, Skipped: result.Skipped, Errors: result.Errors, Failed: result.Failed, NextOffset: result.NextOffset}
+		return report, err
 	}
+
+	batcher := dynamo.NewBatcher(svc, tableName, batchWorkers)
+	result, err := ingest.Stream(ctx, r, csvSchema, batcher, ingest.DefaultMapRow(csvSchema), offset, onRow)
+	report := IngestReport{Processed: result.Processed, Errors: result.Errors, DeadLetters: result.DeadLetters, NextOffset: result.NextOffset}
+	return report, err
 }
 
+// handleRequest is the synchronous ingestion path: it accepts a CSV inline
+// in the request body and is subject to API Gateway's payload size and
+// timeout limits. For larger files, use POST /uploads and POST /ingest
+// (streaming.go) or the async job API in cmd/submit and cmd/worker.
 func handleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Large CSVs go through S3 instead of the inline multipart body below:
+	// POST /uploads issues a pre-signed URL, POST /ingest streams the
+	// uploaded object row-by-row once the client has PUT it to S3.
+	switch request.Path {
+	case "/uploads":
+		return handleUploadRequest(ctx, request)
+	case "/ingest":
+		return handleStreamRequest(ctx, request)
+	}
+
 	if request.HTTPMethod != http.MethodPost {
 		return events.APIGatewayProxyResponse{StatusCode: http.StatusMethodNotAllowed}, nil
 	}
@@ -95,31 +155,28 @@ func handleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (
 
 		// Check the form name to identify the file part
 		if part.FormName() == "file" {
-			csvReader := csv.NewReader(part)
-			csvReader.LazyQuotes = true // Allow lazy quotes to handle bare quotes in fields
-			lines, err := csvReader.ReadAll()
+			seen, err := checkIdempotencyKey(ctx, request)
+			if err != nil {
+				log.Printf("Error checking idempotency key: %v", err)
+				return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Error checking idempotency key"}, nil
+			}
+			if seen {
+				return events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: `{"alreadyProcessed":true}`}, nil
+			}
+
+			report, err := ingestCSV(ctx, part, 0, nil)
 			if err != nil {
 				log.Printf("Error reading CSV: %v", err)
 				return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: fmt.Sprintf("Error reading CSV: %v", err)}, nil
 			}
 
-			var wg sync.WaitGroup
-			// バッファサイズ500のセマフォチャネルを作成する。これにより、同時に500個のゴルーチンが実行可能。
-			// これを超えると、新しいゴルーチンはセマフォからトークンを受け取るまでブロックされ、空きができるまで待機します。
-			sem := make(chan struct{}, 500)
-
-			for _, line := range lines {
-				log.Printf("Processing line: %v", line)
-				// wg.Add(1)を呼び出すことで、goroutineの数をカウントアップします
-				wg.Add(1)
-				// チャネルに空の構造体 struct{}{} を送信することで、セマフォを取得する。
-				// Goでは、空の構造体はメモリを消費しないため、セマフォのトークンとしてよく使用されます。
-				sem <- struct{}{}
-				go processRecord(line, sem, &wg)
+			respBody, merr := json.Marshal(report)
+			if merr != nil {
+				log.Printf("Error marshalling ingest report: %v", merr)
+				return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Error building response"}, nil
 			}
 
-			// wg.Done()で全てのgoroutineが終了するまで待機します
-			wg.Wait()
+			return events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: string(respBody)}, nil
 		}
 	}
 
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/yuktake/go-lambda-csv/internal/ingest"
+)
+
+// checkpointEvery controls how often we log the current row offset so a
+// timed-out Lambda invocation can be resumed from roughly this point.
+const checkpointEvery = 1000
+
+var (
+	s3Bucket = os.Getenv("UPLOAD_BUCKET")
+	s3svc    *s3.S3
+)
+
+func init() {
+	sess := session.Must(session.NewSession())
+	s3svc = s3.New(sess)
+}
+
+// UploadRequest is the JSON body for POST /uploads.
+type UploadRequest struct {
+	// Key is the S3 object key the caller intends to upload to. If empty,
+	// a key is generated from the current time.
+	Key string `json:"key"`
+}
+
+// UploadResponse carries the pre-signed URL the client should PUT the CSV to.
+type UploadResponse struct {
+	Bucket    string `json:"bucket"`
+	Key       string `json:"key"`
+	UploadURL string `json:"uploadUrl"`
+	ExpiresIn int    `json:"expiresIn"`
+}
+
+// handleUploadRequest issues a pre-signed S3 PUT URL so large CSVs can be
+// uploaded directly to S3, bypassing API Gateway's 10 MB payload cap.
+func handleUploadRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if request.HTTPMethod != http.MethodPost {
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusMethodNotAllowed}, nil
+	}
+
+	var req UploadRequest
+	if request.Body != "" {
+		if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+			return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Invalid request body"}, nil
+		}
+	}
+
+	key := req.Key
+	if key == "" {
+		key = "uploads/" + time.Now().UTC().Format("20060102T150405Z") + ".csv"
+	}
+
+	putReq, _ := s3svc.PutObjectRequest(&s3.PutObjectInput{
+		Bucket: aws.String(s3Bucket),
+		Key:    aws.String(key),
+	})
+
+	const presignExpiry = 15 * time.Minute
+	url, err := putReq.Presign(presignExpiry)
+	if err != nil {
+		log.Printf("Error presigning upload URL: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Error creating upload URL"}, nil
+	}
+
+	resp := UploadResponse{
+		Bucket:    s3Bucket,
+		Key:       key,
+		UploadURL: url,
+		ExpiresIn: int(presignExpiry.Seconds()),
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error marshalling upload response: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Error creating upload URL"}, nil
+	}
+
+	return events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: string(body)}, nil
+}
+
+// StreamRequest is the JSON body for POST /ingest, referencing an object
+// already uploaded to S3 via the pre-signed URL from POST /uploads.
+type StreamRequest struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+	// Offset resumes ingestion from this row index, for retrying a timed-out invocation.
+	Offset int `json:"offset"`
+}
+
+// handleStreamRequest streams a CSV object out of S3 row-by-row instead of
+// loading it into memory, and feeds rows through ingestCSV, the same
+// writer (dynamo.Batcher, or dynamo.IdempotentWriter in idempotent mode)
+// used by the inline upload path.
+func handleStreamRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if request.HTTPMethod != http.MethodPost {
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusMethodNotAllowed}, nil
+	}
+
+	var req StreamRequest
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Invalid request body"}, nil
+	}
+	if req.Bucket == "" {
+		req.Bucket = s3Bucket
+	}
+
+	// Only check the whole-file idempotency key on the initial request. A
+	// resume (Offset > 0) is a continuation of a request already recorded
+	// against this same key, not a duplicate submission — checking here
+	// too would make CheckAndRecord report "already processed" the moment
+	// a timed-out invocation's caller retries with its checkpointed offset.
+	if req.Offset == 0 {
+		seen, err := checkIdempotencyKey(ctx, request)
+		if err != nil {
+			log.Printf("Error checking idempotency key: %v", err)
+			return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Error checking idempotency key"}, nil
+		}
+		if seen {
+			return events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: `{"alreadyProcessed":true}`}, nil
+		}
+	}
+
+	out, err := s3svc.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(req.Bucket),
+		Key:    aws.String(req.Key),
+	})
+	if err != nil {
+		log.Printf("Error fetching S3 object s3://%s/%s: %v", req.Bucket, req.Key, err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Error fetching uploaded file"}, nil
+	}
+	defer out.Body.Close()
+
+	var r io.Reader = out.Body
+	if ingest.IsGzip(req.Key, out.ContentEncoding) {
+		gz, err := gzip.NewReader(out.Body)
+		if err != nil {
+			log.Printf("Error creating gzip reader: %v", err)
+			return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: "Error reading gzip CSV"}, nil
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	report, err := ingestCSV(ctx, r, req.Offset, func(rowNum, failed int) {
+		if rowNum%checkpointEvery == 0 {
+			log.Printf("Checkpoint: processed %d rows (%d failed so far) of s3://%s/%s", rowNum, failed, req.Bucket, req.Key)
+		}
+	})
+	if err != nil {
+		if err == context.DeadlineExceeded {
+			// The invocation is about to be killed by Lambda. Report.NextOffset
+			// is the last row we got to, so return what we have instead of
+			// letting the caller's only clue be a CloudWatch log line — the
+			// client can retry with this as StreamRequest.Offset.
+			log.Printf("Invocation deadline reached at row %d of s3://%s/%s, returning partial report", report.NextOffset, req.Bucket, req.Key)
+			respBody, merr := json.Marshal(report)
+			if merr != nil {
+				log.Printf("Error marshalling ingest report: %v", merr)
+				return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Error building response"}, nil
+			}
+			return events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: string(respBody)}, nil
+		}
+		log.Printf("Error reading CSV: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: fmt.Sprintf("Error reading CSV: %v", err)}, nil
+	}
+
+	respBody, merr := json.Marshal(report)
+	if merr != nil {
+		log.Printf("Error marshalling ingest report: %v", merr)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Error building response"}, nil
+	}
+
+	return events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: string(respBody)}, nil
+}